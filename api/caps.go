@@ -0,0 +1,36 @@
+package api
+
+import "encoding/xml"
+
+// capsResponse answers t=caps with the subset of the Newznab capability
+// document gonab actually supports.
+type capsResponse struct {
+	XMLName    xml.Name     `xml:"caps"`
+	Server     capsServer   `xml:"server"`
+	Searching  capsSearches `xml:"searching"`
+	Categories capsCats     `xml:"categories"`
+}
+
+type capsServer struct {
+	Title string `xml:"title,attr"`
+}
+
+type capsSearches struct {
+	Search      capsSearch `xml:"search"`
+	TVSearch    capsSearch `xml:"tv-search"`
+	MovieSearch capsSearch `xml:"movie-search"`
+}
+
+type capsSearch struct {
+	Available       string `xml:"available,attr"`
+	SupportedParams string `xml:"supportedParams,attr"`
+}
+
+type capsCats struct {
+	Category []capsCategory `xml:"category"`
+}
+
+type capsCategory struct {
+	ID   int64  `xml:"id,attr"`
+	Name string `xml:"name,attr"`
+}
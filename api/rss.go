@@ -0,0 +1,52 @@
+package api
+
+import "encoding/xml"
+
+// rss is the root of a Newznab search response: a standard RSS 2.0 feed
+// carrying per-item metadata in the newznab: namespace.
+type rss struct {
+	XMLName      xml.Name   `xml:"rss"`
+	Version      string     `xml:"version,attr"`
+	XmlnsNewznab string     `xml:"xmlns:newznab,attr"`
+	Channel      rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title    string      `xml:"title"`
+	Response rssResponse `xml:"newznab:response"`
+	Items    []rssItem   `xml:"item"`
+}
+
+// rssResponse carries the offset/total paging attributes Sonarr/Radarr use
+// to page through results.
+type rssResponse struct {
+	Offset int `xml:"offset,attr"`
+	Total  int `xml:"total,attr"`
+}
+
+type rssItem struct {
+	Title     string        `xml:"title"`
+	GUID      string        `xml:"guid"`
+	Link      string        `xml:"link"`
+	PubDate   string        `xml:"pubDate"`
+	Enclosure rssEnclosure  `xml:"enclosure"`
+	Attrs     []newznabAttr `xml:"newznab:attr"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+type newznabAttr struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// apiError is the error body Newznab clients expect on failure.
+type apiError struct {
+	XMLName     xml.Name `xml:"error"`
+	Code        int      `xml:"code,attr"`
+	Description string   `xml:"description,attr"`
+}
@@ -0,0 +1,250 @@
+// Package api implements a Newznab-compatible HTTP API in front of the
+// releases gonab indexes, so tools like Sonarr/Radarr/CouchPotato can query
+// gonab the same way they'd query any other indexer.
+package api
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hobeone/gonab/types"
+)
+
+// Store is the surface Server needs from db.Handle.
+type Store interface {
+	SearchReleases(query string, category int64, offset, limit int) ([]types.Release, int, error)
+	GetRelease(id int64) (*types.Release, error)
+	FindUserByAPIKey(key string) (*types.User, error)
+	CreateUser(u *types.User) error
+}
+
+// Server implements the Newznab HTTP API against a Store.
+type Server struct {
+	store   Store
+	baseURL string
+}
+
+// NewServer returns a Server that serves releases from store. baseURL is
+// used to build enclosure/guid links (e.g. http://localhost:9117).
+func NewServer(store Store, baseURL string) *Server {
+	return &Server{store: store, baseURL: baseURL}
+}
+
+// Handler returns the http.Handler to mount - just the single /api endpoint
+// the Newznab protocol multiplexes everything through.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api", s.handleAPI)
+	return mux
+}
+
+func (s *Server) handleAPI(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	switch q.Get("t") {
+	case "caps":
+		s.handleCaps(w)
+	case "register":
+		s.handleRegister(w, q)
+	case "search", "tvsearch", "movie":
+		s.handleSearch(w, q)
+	case "get":
+		s.handleGet(w, q)
+	default:
+		writeError(w, 202, "No such function")
+	}
+}
+
+func (s *Server) authenticate(q map[string][]string) (*types.User, bool) {
+	keys := q["apikey"]
+	if len(keys) == 0 || keys[0] == "" {
+		return nil, false
+	}
+	user, err := s.store.FindUserByAPIKey(keys[0])
+	if err != nil {
+		return nil, false
+	}
+	return user, true
+}
+
+func (s *Server) handleCaps(w http.ResponseWriter) {
+	writeXML(w, capsResponse{
+		Server: capsServer{Title: "gonab"},
+		Searching: capsSearches{
+			Search:      capsSearch{Available: "yes", SupportedParams: "q"},
+			TVSearch:    capsSearch{Available: "yes", SupportedParams: "q,season,ep"},
+			MovieSearch: capsSearch{Available: "yes", SupportedParams: "q"},
+		},
+		Categories: capsCats{Category: []capsCategory{
+			{ID: types.CategoryMovies, Name: "Movies"},
+			{ID: types.CategoryMoviesHD, Name: "Movies/HD"},
+			{ID: types.CategoryMoviesSD, Name: "Movies/SD"},
+			{ID: types.CategoryTV, Name: "TV"},
+			{ID: types.CategoryTVHD, Name: "TV/HD"},
+			{ID: types.CategoryTVSD, Name: "TV/SD"},
+			{ID: types.CategoryBooks, Name: "Books"},
+			{ID: types.CategoryBooksEBook, Name: "Books/EBook"},
+			{ID: types.CategoryOtherMisc, Name: "Other/Misc"},
+		}},
+	})
+}
+
+func (s *Server) handleRegister(w http.ResponseWriter, q map[string][]string) {
+	admin, ok := s.authenticate(q)
+	if !ok || !admin.IsAdmin {
+		writeError(w, 100, "Incorrect user credentials")
+		return
+	}
+
+	username := firstOr(q, "username", "")
+	if username == "" {
+		writeError(w, 200, "Missing parameter (username)")
+		return
+	}
+	key, err := randomAPIKey()
+	if err != nil {
+		writeError(w, 900, "Internal server error")
+		return
+	}
+	user := &types.User{Username: username, APIKey: key, Email: firstOr(q, "email", "")}
+	if err := s.store.CreateUser(user); err != nil {
+		writeError(w, 900, "Internal server error")
+		return
+	}
+	fmt.Fprintf(w, `<register apikey="%s"/>`, key)
+}
+
+func (s *Server) handleSearch(w http.ResponseWriter, q map[string][]string) {
+	if _, ok := s.authenticate(q); !ok {
+		writeError(w, 100, "Incorrect user credentials")
+		return
+	}
+
+	offset := atoiOr(firstOr(q, "offset", "0"), 0)
+	limit := atoiOr(firstOr(q, "limit", "100"), 100)
+	if limit <= 0 || limit > 100 {
+		limit = 100
+	}
+	category := int64(atoiOr(firstOr(q, "cat", "0"), 0))
+
+	query := firstOr(q, "q", "")
+	if seasonEp := seasonEpQuery(firstOr(q, "season", ""), firstOr(q, "ep", "")); seasonEp != "" {
+		query = strings.TrimSpace(query + " " + seasonEp)
+	}
+
+	releases, total, err := s.store.SearchReleases(query, category, offset, limit)
+	if err != nil {
+		writeError(w, 900, "Internal server error")
+		return
+	}
+
+	items := make([]rssItem, len(releases))
+	for i, rel := range releases {
+		items[i] = s.toItem(&rel)
+	}
+	writeXML(w, rss{
+		Version:      "2.0",
+		XmlnsNewznab: "http://www.newznab.com/DTD/2010/feeds/attributes/",
+		Channel: rssChannel{
+			Title:    "gonab",
+			Response: rssResponse{Offset: offset, Total: total},
+			Items:    items,
+		},
+	})
+}
+
+func (s *Server) handleGet(w http.ResponseWriter, q map[string][]string) {
+	if _, ok := s.authenticate(q); !ok {
+		writeError(w, 100, "Incorrect user credentials")
+		return
+	}
+	id := int64(atoiOr(firstOr(q, "id", ""), -1))
+	if id < 0 {
+		writeError(w, 200, "Missing parameter (id)")
+		return
+	}
+	rel, err := s.store.GetRelease(id)
+	if err != nil {
+		writeError(w, 300, "No such item")
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-nzb")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.nzb"`, rel.SearchName))
+	fmt.Fprint(w, rel.NZB)
+}
+
+// seasonEpQuery turns tvsearch's season/ep params into the SxxEyy token
+// gonab's substring search matches against SearchName.
+func seasonEpQuery(season, ep string) string {
+	return seasonEpToken("S", season) + seasonEpToken("E", ep)
+}
+
+func seasonEpToken(prefix, v string) string {
+	if v == "" {
+		return ""
+	}
+	if n, err := strconv.Atoi(v); err == nil {
+		return fmt.Sprintf("%s%02d", prefix, n)
+	}
+	return prefix + v
+}
+
+func (s *Server) toItem(rel *types.Release) rssItem {
+	nzbURL := fmt.Sprintf("%s/api?t=get&id=%d", s.baseURL, rel.ID)
+	return rssItem{
+		Title:   rel.SearchName,
+		GUID:    strconv.FormatInt(rel.ID, 10),
+		Link:    nzbURL,
+		PubDate: rel.Posted.Format(time.RFC1123Z),
+		Enclosure: rssEnclosure{
+			URL:    nzbURL,
+			Length: rel.Size,
+			Type:   "application/x-nzb",
+		},
+		Attrs: []newznabAttr{
+			{Name: "category", Value: strconv.FormatInt(rel.CategoryID, 10)},
+			{Name: "size", Value: strconv.FormatInt(rel.Size, 10)},
+			{Name: "guid", Value: strconv.FormatInt(rel.ID, 10)},
+			{Name: "group", Value: rel.Group.Name},
+		},
+	}
+}
+
+func writeXML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	fmt.Fprint(w, xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, description string) {
+	writeXML(w, apiError{Code: code, Description: description})
+}
+
+func firstOr(q map[string][]string, key, def string) string {
+	if v, ok := q[key]; ok && len(v) > 0 {
+		return v[0]
+	}
+	return def
+}
+
+func atoiOr(s string, def int) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+func randomAPIKey() (string, error) {
+	rb := make([]byte, 16)
+	if _, err := rand.Read(rb); err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.WithPadding(base64.NoPadding).EncodeToString(rb), nil
+}
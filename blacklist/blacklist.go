@@ -0,0 +1,114 @@
+// Package blacklist filters parts and releases against group-scoped regex
+// rules, in either blacklist (drop matches) or whitelist (keep only
+// matches) mode.
+package blacklist
+
+import (
+	"regexp"
+	"sync"
+
+	"github.com/hobeone/gonab/types"
+)
+
+type compiledRule struct {
+	groupRegex *regexp.Regexp
+	regex      *regexp.Regexp
+	msgCol     types.MsgCol
+	whitelist  bool
+}
+
+// Source is the minimal surface Filter needs to load its ruleset;
+// db.Handle satisfies it.
+type Source interface {
+	ListBlacklist() ([]types.Blacklist, error)
+}
+
+// Filter evaluates content against a set of Blacklist rules, compiled once
+// and cached per group so repeated lookups for the same group skip
+// re-matching every rule's GroupRegex.
+type Filter struct {
+	rules []compiledRule
+
+	mu      sync.Mutex
+	byGroup map[string][]compiledRule
+}
+
+// New loads every enabled Blacklist rule from src and compiles it.
+func New(src Source) (*Filter, error) {
+	rows, err := src.ListBlacklist()
+	if err != nil {
+		return nil, err
+	}
+	f := &Filter{byGroup: map[string][]compiledRule{}}
+	for _, r := range rows {
+		if !r.Status {
+			continue
+		}
+		re, err := regexp.Compile(r.Regex)
+		if err != nil {
+			continue
+		}
+		var groupRe *regexp.Regexp
+		if r.GroupRegex != "" {
+			groupRe, err = regexp.Compile(r.GroupRegex)
+			if err != nil {
+				continue
+			}
+		}
+		f.rules = append(f.rules, compiledRule{
+			groupRegex: groupRe,
+			regex:      re,
+			msgCol:     r.MsgCol,
+			whitelist:  r.Whitelist,
+		})
+	}
+	return f, nil
+}
+
+func (f *Filter) rulesForGroup(group string) []compiledRule {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if cached, ok := f.byGroup[group]; ok {
+		return cached
+	}
+	var applicable []compiledRule
+	for _, r := range f.rules {
+		if r.groupRegex == nil || r.groupRegex.MatchString(group) {
+			applicable = append(applicable, r)
+		}
+	}
+	f.byGroup[group] = applicable
+	return applicable
+}
+
+// Allowed reports whether content from the given group, subject and poster
+// should be kept: it's rejected if any blacklist rule matches, or if the
+// group has whitelist rules and none of them match.
+func (f *Filter) Allowed(group, subject, from string) bool {
+	rules := f.rulesForGroup(group)
+	hasWhitelist, whitelistMatch := false, false
+
+	for _, r := range rules {
+		var val string
+		switch r.msgCol {
+		case types.MsgColFrom:
+			val = from
+		case types.MsgColGroup:
+			val = group
+		default:
+			val = subject
+		}
+		matched := r.regex.MatchString(val)
+
+		if r.whitelist {
+			hasWhitelist = true
+			whitelistMatch = whitelistMatch || matched
+			continue
+		}
+		if matched {
+			return false
+		}
+	}
+
+	return !hasWhitelist || whitelistMatch
+}
@@ -0,0 +1,88 @@
+// Package categorize sorts releases into Newznab categories (Movies, TV,
+// Books, ...) using regex rules imported from nzedb.
+package categorize
+
+import (
+	"regexp"
+	"sort"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/hobeone/gonab/types"
+)
+
+// Categorizer assigns a Newznab category ID to a release given its search
+// name and the name of the group it was posted to.
+type Categorizer interface {
+	Categorize(searchName, groupName string) int64
+}
+
+// RegexSource is the minimal surface DefaultCategorizer needs to load its
+// ruleset; db.Handle satisfies it via RegexImporter's CategoryRegex table.
+type RegexSource interface {
+	ListCategoryRegexes() ([]types.CategoryRegex, error)
+}
+
+type rule struct {
+	groupRegex *regexp.Regexp
+	regex      *regexp.Regexp
+	categoryID int64
+	ordinal    int
+}
+
+// DefaultCategorizer matches a release's SearchName (and posting Group)
+// against an ordered ruleset loaded from the database, falling back to
+// types.CategoryOtherMisc when nothing matches.
+type DefaultCategorizer struct {
+	rules []rule
+}
+
+// NewDefaultCategorizer loads every enabled CategoryRegex from src and
+// compiles it, trying rules in ascending ordinal order - the same order
+// nzedb applies its own category regexes in.
+func NewDefaultCategorizer(src RegexSource) (*DefaultCategorizer, error) {
+	regexes, err := src.ListCategoryRegexes()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &DefaultCategorizer{}
+	for _, r := range regexes {
+		if !r.Status {
+			continue
+		}
+		re, err := regexp.Compile(r.Regex)
+		if err != nil {
+			logrus.Warnf("Skipping invalid category regex %d (%s): %v", r.ID, r.Description, err)
+			continue
+		}
+		var groupRe *regexp.Regexp
+		if r.GroupRegex != "" {
+			groupRe, err = regexp.Compile(r.GroupRegex)
+			if err != nil {
+				logrus.Warnf("Skipping category regex %d with invalid group regex: %v", r.ID, err)
+				continue
+			}
+		}
+		c.rules = append(c.rules, rule{
+			groupRegex: groupRe,
+			regex:      re,
+			categoryID: r.CategoryID,
+			ordinal:    r.Ordinal,
+		})
+	}
+	sort.Slice(c.rules, func(i, j int) bool { return c.rules[i].ordinal < c.rules[j].ordinal })
+	return c, nil
+}
+
+// Categorize implements Categorizer.
+func (c *DefaultCategorizer) Categorize(searchName, groupName string) int64 {
+	for _, r := range c.rules {
+		if r.groupRegex != nil && !r.groupRegex.MatchString(groupName) {
+			continue
+		}
+		if r.regex.MatchString(searchName) {
+			return r.categoryID
+		}
+	}
+	return types.CategoryOtherMisc
+}
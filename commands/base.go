@@ -28,6 +28,21 @@ func SetupCommands() {
 
 	regexcmd := &RegexImporter{}
 	App.Command("importregex", "Import regexes from nzedb").Action(regexcmd.run)
+
+	recat := &RecategorizeCommand{}
+	App.Command("recategorize", "Recompute categories for all existing releases").Action(recat.run)
+
+	blcmd := &BlacklistCommand{}
+	blcmd.configure(App)
+
+	servecmd := &ServeCommand{}
+	servecmd.configure(App)
+
+	importcmd := &ImportNZBCommand{}
+	importcmd.configure(App)
+
+	usercmd := &AddUserCommand{}
+	usercmd.configure(App)
 }
 
 func loadConfig(cfile string) *config.Config {
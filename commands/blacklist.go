@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/hobeone/gonab/db"
+	"github.com/hobeone/gonab/types"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// BlacklistCommand groups the blacklist/whitelist management subcommands.
+type BlacklistCommand struct {
+	groupRegex  string
+	regex       string
+	col         string
+	whitelist   bool
+	description string
+	id          int64
+}
+
+func (cmd *BlacklistCommand) configure(app *kingpin.Application) {
+	bl := app.Command("blacklist", "Manage blacklist/whitelist rules")
+
+	add := bl.Command("add", "Add a new rule").Action(cmd.add)
+	add.Flag("group-regex", "Regex matching the groups this rule applies to").StringVar(&cmd.groupRegex)
+	add.Flag("regex", "Regex to match against").Required().StringVar(&cmd.regex)
+	add.Flag("col", "Column to match: subject, from or group").Default(string(types.MsgColSubject)).StringVar(&cmd.col)
+	add.Flag("whitelist", "Treat this as a whitelist rule instead of a blacklist rule").BoolVar(&cmd.whitelist)
+	add.Flag("description", "Human readable description").StringVar(&cmd.description)
+
+	bl.Command("list", "List all rules").Action(cmd.list)
+
+	del := bl.Command("delete", "Delete a rule").Action(cmd.delete)
+	del.Arg("id", "Rule id").Required().Int64Var(&cmd.id)
+
+	toggle := bl.Command("toggle", "Enable or disable a rule").Action(cmd.toggle)
+	toggle.Arg("id", "Rule id").Required().Int64Var(&cmd.id)
+}
+
+func (cmd *BlacklistCommand) add(c *kingpin.ParseContext) error {
+	dbh, err := db.NewDBHandle(loadConfig(""))
+	if err != nil {
+		return err
+	}
+	return dbh.CreateBlacklist(&types.Blacklist{
+		GroupRegex:  cmd.groupRegex,
+		Regex:       cmd.regex,
+		MsgCol:      types.MsgCol(cmd.col),
+		Whitelist:   cmd.whitelist,
+		Status:      true,
+		Description: cmd.description,
+	})
+}
+
+func (cmd *BlacklistCommand) list(c *kingpin.ParseContext) error {
+	dbh, err := db.NewDBHandle(loadConfig(""))
+	if err != nil {
+		return err
+	}
+	rules, err := dbh.ListBlacklist()
+	if err != nil {
+		return err
+	}
+	for _, r := range rules {
+		fmt.Printf("%d: [%s] /%s/ group=%q whitelist=%v status=%v %s\n",
+			r.ID, r.MsgCol, r.Regex, r.GroupRegex, r.Whitelist, r.Status, r.Description)
+	}
+	return nil
+}
+
+func (cmd *BlacklistCommand) delete(c *kingpin.ParseContext) error {
+	dbh, err := db.NewDBHandle(loadConfig(""))
+	if err != nil {
+		return err
+	}
+	return dbh.DeleteBlacklist(cmd.id)
+}
+
+func (cmd *BlacklistCommand) toggle(c *kingpin.ParseContext) error {
+	dbh, err := db.NewDBHandle(loadConfig(""))
+	if err != nil {
+		return err
+	}
+	return dbh.ToggleBlacklist(cmd.id)
+}
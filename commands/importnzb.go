@@ -0,0 +1,108 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/hobeone/gonab/categorize"
+	"github.com/hobeone/gonab/db"
+	"github.com/hobeone/gonab/nzb"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// ImportNZBCommand bulk imports .nzb files produced by another indexer
+// straight into the release table, skipping the binaries/parts staging
+// gonab normally assembles its own releases through.
+type ImportNZBCommand struct {
+	path    string
+	workers int
+}
+
+func (cmd *ImportNZBCommand) configure(app *kingpin.Application) {
+	c := app.Command("importnzb", "Import .nzb files from another indexer").Action(cmd.run)
+	c.Arg("path", "Directory or glob of .nzb files to import").Required().StringVar(&cmd.path)
+	c.Flag("workers", "Number of files to parse concurrently").Default("4").IntVar(&cmd.workers)
+}
+
+func (cmd *ImportNZBCommand) run(c *kingpin.ParseContext) error {
+	files, err := nzbFiles(cmd.path)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		logrus.Infof("No .nzb files found under %s", cmd.path)
+		return nil
+	}
+
+	cfg := loadConfig("")
+	dbh, err := db.NewDBHandle(cfg)
+	if err != nil {
+		return err
+	}
+	cat, err := categorize.NewDefaultCategorizer(dbh)
+	if err != nil {
+		return err
+	}
+
+	paths := make(chan string)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var imported, skipped int
+
+	for i := 0; i < cmd.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				ok, err := importNZBFile(dbh, cat, path)
+				mu.Lock()
+				switch {
+				case err != nil:
+					logrus.Errorf("Importing %s: %v", path, err)
+				case ok:
+					imported++
+				default:
+					skipped++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, f := range files {
+		paths <- f
+	}
+	close(paths)
+	wg.Wait()
+
+	logrus.Infof("Imported %d release(s), skipped %d duplicate(s)", imported, skipped)
+	return nil
+}
+
+// nzbFiles expands path into the list of .nzb files to import: every *.nzb
+// under path if it's a directory, or the glob matches of path otherwise.
+func nzbFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err == nil && info.IsDir() {
+		return filepath.Glob(filepath.Join(path, "*.nzb"))
+	}
+	return filepath.Glob(path)
+}
+
+// importNZBFile parses a single .nzb file and saves it as a Release. It
+// reports whether the file was imported, as opposed to skipped because its
+// content hash matched a release already in the database.
+func importNZBFile(dbh *db.Handle, cat categorize.Categorizer, path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	parsed, err := nzb.ParseNZB(f)
+	if err != nil {
+		return false, err
+	}
+	return dbh.ImportRelease(parsed.Binary, parsed.Meta, cat)
+}
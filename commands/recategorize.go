@@ -0,0 +1,42 @@
+package commands
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/hobeone/gonab/categorize"
+	"github.com/hobeone/gonab/db"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// RecategorizeCommand walks every existing Release and reassigns its
+// CategoryID using the current set of category regexes.
+type RecategorizeCommand struct{}
+
+func (cmd *RecategorizeCommand) run(c *kingpin.ParseContext) error {
+	cfg := loadConfig("")
+	dbh, err := db.NewDBHandle(cfg)
+	if err != nil {
+		return err
+	}
+
+	cat, err := categorize.NewDefaultCategorizer(dbh)
+	if err != nil {
+		return err
+	}
+
+	releases, err := dbh.ListAllReleases()
+	if err != nil {
+		return err
+	}
+
+	for _, r := range releases {
+		catID := cat.Categorize(r.SearchName, r.Group.Name)
+		if catID == r.CategoryID {
+			continue
+		}
+		if err := dbh.UpdateReleaseCategory(r.ID, catID); err != nil {
+			return err
+		}
+		logrus.Infof("Recategorized %s: %d -> %d", r.SearchName, r.CategoryID, catID)
+	}
+	return nil
+}
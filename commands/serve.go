@@ -0,0 +1,29 @@
+package commands
+
+import (
+	"net/http"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/hobeone/gonab/api"
+	"github.com/hobeone/gonab/db"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// ServeCommand starts the Newznab-compatible HTTP API server.
+type ServeCommand struct{}
+
+func (cmd *ServeCommand) configure(app *kingpin.Application) {
+	app.Command("serve", "Serve the Newznab compatible HTTP API").Action(cmd.run)
+}
+
+func (cmd *ServeCommand) run(c *kingpin.ParseContext) error {
+	cfg := loadConfig("")
+	dbh, err := db.NewDBHandle(cfg)
+	if err != nil {
+		return err
+	}
+
+	srv := api.NewServer(dbh, cfg.Serve.BaseURL)
+	logrus.Infof("Serving Newznab API on %s", cfg.Serve.Addr)
+	return http.ListenAndServe(cfg.Serve.Addr, srv.Handler())
+}
@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/hobeone/gonab/db"
+	"github.com/hobeone/gonab/types"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// AddUserCommand creates a new API user, for bootstrapping the admin
+// account t=register needs someone to already hold.
+type AddUserCommand struct {
+	username string
+	email    string
+	admin    bool
+}
+
+func (cmd *AddUserCommand) configure(app *kingpin.Application) {
+	c := app.Command("adduser", "Create a new API user").Action(cmd.run)
+	c.Arg("username", "Username").Required().StringVar(&cmd.username)
+	c.Flag("email", "Email address").StringVar(&cmd.email)
+	c.Flag("admin", "Allow this user to register other users via t=register").BoolVar(&cmd.admin)
+}
+
+func (cmd *AddUserCommand) run(c *kingpin.ParseContext) error {
+	dbh, err := db.NewDBHandle(loadConfig(""))
+	if err != nil {
+		return err
+	}
+
+	key, err := randomAPIKey()
+	if err != nil {
+		return err
+	}
+	user := &types.User{
+		Username: cmd.username,
+		Email:    cmd.email,
+		APIKey:   key,
+		IsAdmin:  cmd.admin,
+	}
+	if err := dbh.CreateUser(user); err != nil {
+		return err
+	}
+	fmt.Printf("Created user %q with apikey %s\n", user.Username, user.APIKey)
+	return nil
+}
+
+func randomAPIKey() (string, error) {
+	rb := make([]byte, 16)
+	if _, err := rand.Read(rb); err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.WithPadding(base64.NoPadding).EncodeToString(rb), nil
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Supported values for DBConfig.Type.
+const (
+	DBTypeSQLite   = "sqlite3"
+	DBTypePostgres = "postgres"
+)
+
+// Supported values for DBConfig.PartsBackend.
+const (
+	PartsBackendSQL     = "sql"
+	PartsBackendLevelDB = "leveldb"
+)
+
+// DBConfig holds the settings needed to open gonab's database backend.
+type DBConfig struct {
+	// Type selects the relational backend used for Group/Release/Binary:
+	// "sqlite3" or "postgres".
+	Type string `json:"type"`
+	// Path is the sqlite3 database file path. Only used when Type is sqlite3.
+	Path string `json:"path"`
+	// DSN is the connection string passed to the postgres driver. Only used
+	// when Type is postgres.
+	DSN string `json:"dsn"`
+	// PartsBackend selects where high-churn Part/Segment rows live: "sql"
+	// (alongside everything else) or "leveldb" (an embedded KV store at
+	// PartsPath).
+	PartsBackend string `json:"parts_backend"`
+	// PartsPath is the goleveldb directory. Only used when PartsBackend is
+	// leveldb.
+	PartsPath string `json:"parts_path"`
+	// Verbose logs every query at debug level when true.
+	Verbose bool `json:"verbose"`
+}
+
+// IngestConfig tunes the MakeBinaries streaming ingest pipeline.
+type IngestConfig struct {
+	// Workers is the number of binary-aggregation shards/goroutines.
+	Workers int `json:"workers"`
+	// PageSize is how many parts are pulled from the part store per cursor page.
+	PageSize int `json:"page_size"`
+	// BatchSize is how many binaries a shard accumulates before flushing.
+	BatchSize int `json:"batch_size"`
+}
+
+// ServeConfig tunes the Newznab-compatible HTTP API server.
+type ServeConfig struct {
+	// Addr is the address the server listens on, e.g. ":9117".
+	Addr string `json:"addr"`
+	// BaseURL is used to build enclosure/guid links returned to clients, e.g.
+	// "http://localhost:9117".
+	BaseURL string `json:"base_url"`
+}
+
+// Config is the top level gonab configuration.
+type Config struct {
+	DB     DBConfig     `json:"db"`
+	Ingest IngestConfig `json:"ingest"`
+	Serve  ServeConfig  `json:"serve"`
+}
+
+// NewConfig returns a Config populated with sane defaults.
+func NewConfig() *Config {
+	return &Config{
+		DB: DBConfig{
+			Type:         DBTypeSQLite,
+			Path:         "gonab.db",
+			PartsBackend: PartsBackendSQL,
+		},
+		Ingest: IngestConfig{
+			Workers:   4,
+			PageSize:  1000,
+			BatchSize: 1000,
+		},
+		Serve: ServeConfig{
+			Addr:    ":9117",
+			BaseURL: "http://localhost:9117",
+		},
+	}
+}
+
+// ReadConfig loads and decodes a JSON config file from disk, overwriting c.
+func (c *Config) ReadConfig(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewDecoder(f).Decode(c)
+}
+
+// Validate checks that the configured DB backend combination makes sense.
+func (c *Config) Validate() error {
+	switch c.DB.Type {
+	case DBTypeSQLite, DBTypePostgres:
+	default:
+		return fmt.Errorf("unknown db type %q", c.DB.Type)
+	}
+	switch c.DB.PartsBackend {
+	case PartsBackendSQL, PartsBackendLevelDB:
+	default:
+		return fmt.Errorf("unknown parts_backend %q", c.DB.PartsBackend)
+	}
+	if c.DB.PartsBackend == PartsBackendLevelDB && c.DB.PartsPath == "" {
+		return fmt.Errorf("parts_path is required when parts_backend is leveldb")
+	}
+	return nil
+}
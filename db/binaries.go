@@ -0,0 +1,287 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/OneOfOne/xxhash"
+	"github.com/davecgh/go-spew/spew"
+	"github.com/hobeone/gonab/blacklist"
+	"github.com/hobeone/gonab/types"
+	"github.com/jinzhu/gorm"
+)
+
+// matchedPart is a Part annotated with the binary identity extracted from
+// its subject, ready to be routed to the shard that owns that binary.
+type matchedPart struct {
+	part       types.Part
+	binHash    string
+	name       string
+	group      string
+	from       string
+	totalParts int
+}
+
+// matchPart runs the subject regex (and the PartRegex fallback) against
+// p.Subject, returning the binary it belongs to. A name is always generated
+// if the regex yields none; ok is false if no "x/y" parts count could be
+// extracted.
+func matchPart(rc types.RegexpUtil, p types.Part) (matchedPart, bool) {
+	m := rc.FindStringSubmatchMap(p.Subject)
+	for k, v := range m {
+		m[k] = strings.TrimSpace(v)
+	}
+
+	// fill name if reqid is available
+	if reqid, ok := m["reqid"]; ok {
+		if _, okname := m["name"]; !okname {
+			m["name"] = reqid
+		}
+	}
+
+	// Generate a name if we don't have one
+	if _, ok := m["name"]; !ok {
+		var matchvalues []string
+		for _, v := range m {
+			matchvalues = append(matchvalues, v)
+		}
+		m["name"] = strings.Join(matchvalues, " ")
+	}
+
+	// Look for parts manually if the regex didn't return some
+	if _, ok := m["parts"]; !ok {
+		partmatch := PartRegex.FindStringSubmatch(p.Subject)
+		if partmatch != nil {
+			m["parts"] = partmatch[1]
+		}
+	}
+	if !hasNameAndParts(m) {
+		fmt.Printf("Couldn't find Name and Parts for %s\n", p.Subject)
+		spew.Dump(m)
+		return matchedPart{}, false
+	}
+
+	// Clean name of '-', '~', ' of '
+	if strings.Index(m["parts"], "/") == -1 {
+		m["parts"] = strings.Replace(m["parts"], "-", "/", -1)
+		m["parts"] = strings.Replace(m["parts"], "~", "/", -1)
+		m["parts"] = strings.Replace(m["parts"], " of ", "/", -1)
+		m["parts"] = strings.Replace(m["parts"], "[", "", -1)
+		m["parts"] = strings.Replace(m["parts"], "]", "", -1)
+		m["parts"] = strings.Replace(m["parts"], "(", "", -1)
+		m["parts"] = strings.Replace(m["parts"], ")", "", -1)
+	}
+	if strings.Index(m["parts"], "/") == -1 {
+		fmt.Printf("Couldn't find valid parts information for %s (%s didn't include /)\n", p.Subject, m["parts"])
+		return matchedPart{}, false
+	}
+
+	partcounts := strings.SplitN(m["parts"], "/", 2)
+	totalparts, _ := strconv.Atoi(partcounts[1])
+
+	return matchedPart{
+		part:       p,
+		binHash:    makeBinaryHash(m["name"], p.Group, p.From, partcounts[1]),
+		name:       m["name"],
+		group:      p.Group,
+		from:       p.From,
+		totalParts: totalparts,
+	}, true
+}
+
+// binShard accumulates the matched parts routed to it between flushes. A
+// shard is only ever touched by the single worker goroutine that owns it,
+// so no locking is needed.
+type binShard struct {
+	bins    map[string]*types.Binary
+	partIDs map[string][]int64
+}
+
+func newBinShard() *binShard {
+	return &binShard{bins: map[string]*types.Binary{}, partIDs: map[string][]int64{}}
+}
+
+func (s *binShard) add(mp matchedPart) {
+	if bin, ok := s.bins[mp.binHash]; ok {
+		bin.Parts = append(bin.Parts, mp.part)
+	} else {
+		s.bins[mp.binHash] = &types.Binary{
+			Hash:       mp.binHash,
+			Name:       mp.name,
+			Posted:     mp.part.Posted,
+			From:       mp.from,
+			Group:      mp.group,
+			TotalParts: mp.totalParts,
+			Parts:      []types.Part{mp.part},
+		}
+	}
+	s.partIDs[mp.binHash] = append(s.partIDs[mp.binHash], mp.part.ID)
+}
+
+// MakeBinaries streams parts with no binary_id through a sharded worker
+// pool: a cursor pages through the part store so memory use stays bounded,
+// each part is matched against the subject regex and routed by
+// xxhash(hash) % workers so a given binary always lands on the same shard,
+// and each shard periodically flushes its accumulated binaries with a
+// single batched upsert plus a bulk part update.
+func (d *Handle) MakeBinaries() error {
+	workers := d.ingest.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	pageSize := d.ingest.PageSize
+	if pageSize < 1 {
+		pageSize = 1000
+	}
+	batchSize := d.ingest.BatchSize
+	if batchSize < 1 {
+		batchSize = 1000
+	}
+
+	rc := types.RegexpUtil{types.BinarySubjectRegex}
+
+	bl, err := blacklist.New(d)
+	if err != nil {
+		return err
+	}
+
+	shardChans := make([]chan matchedPart, workers)
+	for i := range shardChans {
+		shardChans[i] = make(chan matchedPart, batchSize)
+	}
+
+	var wg sync.WaitGroup
+	// errs is drained continuously below so a worker can never block on a
+	// full buffer - a persistent DB failure can push far more than one
+	// error per worker before the page cursor is exhausted.
+	errs := make(chan error)
+	var errMu sync.Mutex
+	var firstErr error
+	var errWG sync.WaitGroup
+	errWG.Add(1)
+	go func() {
+		defer errWG.Done()
+		for err := range errs {
+			errMu.Lock()
+			if firstErr == nil {
+				firstErr = err
+			}
+			errMu.Unlock()
+		}
+	}()
+
+	// shutdown stops every worker and the error collector, for use on any
+	// early-return error path below.
+	shutdown := func() {
+		for _, ch := range shardChans {
+			close(ch)
+		}
+		wg.Wait()
+		close(errs)
+		errWG.Wait()
+	}
+
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func(ch <-chan matchedPart) {
+			defer wg.Done()
+			shard := newBinShard()
+			for mp := range ch {
+				shard.add(mp)
+				if len(shard.bins) >= batchSize {
+					if err := d.flushShard(shard); err != nil {
+						errs <- err
+						shard = newBinShard()
+					}
+				}
+			}
+			if len(shard.bins) > 0 {
+				if err := d.flushShard(shard); err != nil {
+					errs <- err
+				}
+			}
+		}(shardChans[i])
+	}
+
+	var afterID int64
+	for {
+		page, err := d.parts.PartsPage(afterID, pageSize)
+		if err != nil {
+			shutdown()
+			return err
+		}
+		if len(page) == 0 {
+			break
+		}
+		for _, p := range page {
+			if !bl.Allowed(p.Group, p.Subject, p.From) {
+				// Drop it so it isn't paged through again on the next run.
+				if err := d.parts.DeletePart(p.ID); err != nil {
+					shutdown()
+					return err
+				}
+				continue
+			}
+			mp, ok := matchPart(rc, p)
+			if !ok {
+				continue
+			}
+			shardChans[xxhash.Checksum64([]byte(mp.binHash))%uint64(workers)] <- mp
+		}
+		afterID = page[len(page)-1].ID
+	}
+
+	shutdown()
+	return firstErr
+}
+
+// flushShard upserts every binary a shard has accumulated and bulk-assigns
+// their parts' binary_id, then clears the shard for the next batch.
+func (d *Handle) flushShard(s *binShard) error {
+	for hash, bin := range s.bins {
+		if err := d.upsertBinary(bin); err != nil {
+			return err
+		}
+		if err := d.parts.BulkSetBinaryID(s.partIDs[hash], bin.ID); err != nil {
+			return err
+		}
+	}
+	s.bins = map[string]*types.Binary{}
+	s.partIDs = map[string][]int64{}
+	return nil
+}
+
+// upsertBinary inserts b or, if a binary with the same hash already exists,
+// updates it in place - populating b.ID either way. Postgres gets a real
+// INSERT ... ON CONFLICT DO UPDATE; the sqlite3 driver gonab bundles
+// predates UPSERT support, so it's emulated with a lookup.
+func (d *Handle) upsertBinary(b *types.Binary) error {
+	if d.DB.Dialect().GetName() == "postgres" {
+		return d.DB.Raw(`INSERT INTO binary (hash, name, posted, "group", "from", total_parts)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT (hash) DO UPDATE SET
+				name = excluded.name, posted = excluded.posted, "group" = excluded."group",
+				"from" = excluded."from", total_parts = excluded.total_parts
+			RETURNING id`,
+			b.Hash, b.Name, b.Posted, b.Group, b.From, b.TotalParts).Row().Scan(&b.ID)
+	}
+
+	existing := &types.Binary{}
+	err := d.DB.Where("hash = ?", b.Hash).First(existing).Error
+	if err != nil && err != gorm.RecordNotFound {
+		return err
+	}
+	if existing.ID != 0 {
+		b.ID = existing.ID
+		return d.DB.Model(existing).Updates(map[string]interface{}{
+			"name":        b.Name,
+			"posted":      b.Posted,
+			"from":        b.From,
+			"group":       b.Group,
+			"total_parts": b.TotalParts,
+		}).Error
+	}
+	return d.DB.Save(b).Error
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hobeone/gonab/types"
+)
+
+// seedUnmatchedParts inserts n parts with subjects the binary regex can
+// parse, spread across distinct binaries, so BenchmarkMakeBinaries has
+// real grouping work to shard across workers.
+func seedUnmatchedParts(b *testing.B, dbh *Handle, n, binaries int) {
+	for i := 0; i < n; i++ {
+		binIdx := i % binaries
+		part := &types.Part{
+			Subject: fmt.Sprintf(`[%d/20] "binary-%d.mkv" yEnc`, i%20+1, binIdx),
+			From:    "poster@example.com",
+			Group:   "alt.binaries.test",
+			Posted:  time.Unix(int64(i), 0),
+		}
+		if err := dbh.CreatePart(part); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkMakeBinaries seeds a corpus of unmatched parts and times the
+// sharded ingest pipeline against it - the workload the xxhash-sharded
+// worker pool redesign targets.
+func BenchmarkMakeBinaries(b *testing.B) {
+	const partsPerRun = 20000
+	const binariesPerRun = 500
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		dbh := NewMemoryDBHandle(false)
+		seedUnmatchedParts(b, dbh, partsPerRun, binariesPerRun)
+		b.StartTimer()
+
+		if err := dbh.MakeBinaries(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
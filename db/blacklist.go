@@ -0,0 +1,30 @@
+package db
+
+import "github.com/hobeone/gonab/types"
+
+// ListBlacklist returns every Blacklist row.
+func (d *Handle) ListBlacklist() ([]types.Blacklist, error) {
+	var bl []types.Blacklist
+	err := d.DB.Find(&bl).Error
+	return bl, err
+}
+
+// CreateBlacklist saves a new Blacklist rule.
+func (d *Handle) CreateBlacklist(b *types.Blacklist) error {
+	return d.DB.Save(b).Error
+}
+
+// DeleteBlacklist removes the Blacklist rule with the given id.
+func (d *Handle) DeleteBlacklist(id int64) error {
+	return d.DB.Delete(&types.Blacklist{ID: id}).Error
+}
+
+// ToggleBlacklist flips Status on the Blacklist rule with the given id.
+func (d *Handle) ToggleBlacklist(id int64) error {
+	var b types.Blacklist
+	if err := d.DB.First(&b, id).Error; err != nil {
+		return err
+	}
+	b.Status = !b.Status
+	return d.DB.Save(&b).Error
+}
@@ -0,0 +1,11 @@
+package db
+
+import "github.com/hobeone/gonab/types"
+
+// ListCategoryRegexes returns every CategoryRegex row ordered the way
+// categorize.NewDefaultCategorizer expects to apply them in.
+func (d *Handle) ListCategoryRegexes() ([]types.CategoryRegex, error) {
+	var regexes []types.CategoryRegex
+	err := d.DB.Order("ordinal").Find(&regexes).Error
+	return regexes, err
+}
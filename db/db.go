@@ -5,17 +5,20 @@ import (
 	"encoding/base64"
 	"fmt"
 	"regexp"
-	"strconv"
 	"strings"
 	"sync"
 
 	"github.com/OneOfOne/xxhash"
 	"github.com/Sirupsen/logrus"
-	"github.com/davecgh/go-spew/spew"
+	"github.com/hobeone/gonab/blacklist"
+	"github.com/hobeone/gonab/categorize"
+	"github.com/hobeone/gonab/config"
 	"github.com/hobeone/gonab/nzb"
 	"github.com/hobeone/gonab/types"
 	"github.com/jinzhu/gorm"
 
+	// Import postgres
+	_ "github.com/lib/pq"
 	// Import sqlite
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -23,8 +26,13 @@ import (
 //Handle Struct
 type Handle struct {
 	DB           gorm.DB
+	parts        PartStore
+	ingest       config.IngestConfig
 	writeUpdates bool
 	syncMutex    sync.Mutex
+
+	relIndex      *releaseIndex
+	relIndexMutex sync.Mutex
 }
 
 // debugLogger satisfies Gorm's logger interface
@@ -53,15 +61,10 @@ func openDB(dbType string, dbArgs string, verbose bool) gorm.DB {
 	return d
 }
 
-func setupDB(db gorm.DB) error {
-	tx := db.Begin()
-	err := tx.AutoMigrate(&types.Group{}, &types.Release{}, &types.Binary{}, &types.Part{}, &types.Segment{}, &types.Regex{}).Error
-	if err != nil {
-		tx.Rollback()
-		return err
-	}
-	tx.Commit()
-	err = db.Exec("PRAGMA journal_mode=WAL;").Error
+// sqliteInit applies the SQLite-specific pragmas gonab relies on for
+// acceptable write throughput. Postgres manages these itself.
+func sqliteInit(db gorm.DB) error {
+	err := db.Exec("PRAGMA journal_mode=WAL;").Error
 	if err != nil {
 		return err
 	}
@@ -69,55 +72,87 @@ func setupDB(db gorm.DB) error {
 	if err != nil {
 		return err
 	}
-	err = db.Exec("PRAGMA encoding = \"UTF-8\";").Error
+	return db.Exec("PRAGMA encoding = \"UTF-8\";").Error
+}
+
+func setupDB(db gorm.DB, dbType string) error {
+	tx := db.Begin()
+	err := tx.AutoMigrate(&types.Group{}, &types.Release{}, &types.Binary{}, &types.Part{}, &types.Segment{}, &types.Regex{}, &types.CategoryRegex{}, &types.Blacklist{}, &types.User{}).Error
 	if err != nil {
+		tx.Rollback()
 		return err
 	}
+	tx.Commit()
 
+	if dbType == config.DBTypeSQLite {
+		return sqliteInit(db)
+	}
 	return nil
 }
 
-func constructDBPath(dbpath string, memory bool) string {
-	mode := "rwc"
-	if memory {
-		mode = "memory"
+// constructDSN builds the driver-specific connection string for cfg.
+func constructDSN(cfg *config.DBConfig, memory bool) string {
+	switch cfg.Type {
+	case config.DBTypePostgres:
+		return cfg.DSN
+	default:
+		mode := "rwc"
+		if memory {
+			mode = "memory"
+		}
+		return fmt.Sprintf("file:%s?cache=shared&mode=%s", cfg.Path, mode)
 	}
-	return fmt.Sprintf("file:%s?cache=shared&mode=%s", dbpath, mode)
 }
 
-// CreateAndMigrateDB will create a new database on disk and create all tables.
-func CreateAndMigrateDB(dbpath string, verbose bool) (*Handle, error) {
-	constructedPath := constructDBPath(dbpath, false)
-	db := openDB("sqlite3", constructedPath, verbose)
-	err := setupDB(db)
+// CreateAndMigrateDB will create a new database and create all tables using
+// the backend selected by cfg.
+func CreateAndMigrateDB(cfg *config.Config) (*Handle, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	db := openDB(cfg.DB.Type, constructDSN(&cfg.DB, false), cfg.DB.Verbose)
+	err := setupDB(db, cfg.DB.Type)
 	if err != nil {
 		return nil, err
 	}
-	return &Handle{DB: db}, nil
+	parts, err := newPartStore(&cfg.DB, db)
+	if err != nil {
+		return nil, err
+	}
+	return &Handle{DB: db, parts: parts, ingest: cfg.Ingest}, nil
 }
 
-// NewDBHandle creates a new DBHandle
-//	dbpath: the path to the database to use.
-//	verbose: when true database accesses are logged to stdout
-func NewDBHandle(dbpath string, verbose bool) *Handle {
-	constructedPath := constructDBPath(dbpath, false)
-	db := openDB("sqlite3", constructedPath, verbose)
-	return &Handle{DB: db}
+// NewDBHandle creates a new DBHandle using the backend selected by cfg.
+func NewDBHandle(cfg *config.Config) (*Handle, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	db := openDB(cfg.DB.Type, constructDSN(&cfg.DB, false), cfg.DB.Verbose)
+	parts, err := newPartStore(&cfg.DB, db)
+	if err != nil {
+		return nil, err
+	}
+	return &Handle{DB: db, parts: parts, ingest: cfg.Ingest}, nil
 }
 
-// NewMemoryDBHandle creates a new in memory database.  Only used for testing.
-// The name of the database is a random string so multiple tests can run in
-// parallel with their own database.  This will setup the database with the
-// all the tables as well.
+// NewMemoryDBHandle creates a new in memory sqlite3 database.  Only used for
+// testing.  The name of the database is a random string so multiple tests
+// can run in parallel with their own database.  This will setup the
+// database with all the tables as well.
 func NewMemoryDBHandle(verbose bool) *Handle {
-	dbpath := randString()
-	constructedPath := constructDBPath(dbpath, true)
-	db := openDB("sqlite3", constructedPath, verbose)
-	err := setupDB(db)
+	cfg := config.NewConfig()
+	cfg.DB.Path = randString()
+	cfg.DB.Verbose = verbose
+	db := openDB(cfg.DB.Type, constructDSN(&cfg.DB, true), cfg.DB.Verbose)
+	err := setupDB(db, cfg.DB.Type)
+	if err != nil {
+		panic(err.Error())
+	}
+	parts, err := newPartStore(&cfg.DB, db)
 	if err != nil {
 		panic(err.Error())
 	}
-	return &Handle{DB: db}
+	return &Handle{DB: db, parts: parts, ingest: cfg.Ingest}
 }
 
 func randString() string {
@@ -131,13 +166,12 @@ func randString() string {
 
 // CreatePart func
 func (d *Handle) CreatePart(p *types.Part) error {
-	return d.DB.Save(p).Error
+	return d.parts.Create(p)
 }
 
 // ListParts func
 func (d *Handle) ListParts() {
-	var parts []types.Part
-	err := d.DB.Preload("Segments").Find(&parts).Error
+	parts, err := d.parts.All()
 	if err != nil {
 		fmt.Printf("Error getting parts: %v\n", err)
 	}
@@ -184,96 +218,6 @@ func makeBinaryHash(name, group, from, totalParts string) string {
 	return fmt.Sprintf("%x", h.Sum64())
 }
 
-// MakeBinaries comment
-func (d *Handle) MakeBinaries() error {
-	r := `(?i).*?(?P<parts>\d{1,3}\/\d{1,3}).*?\"(?P<name>.*?)\.(sample|mkv|Avi|mp4|vol|ogm|par|rar|sfv|nfo|nzb|srt|ass|mpg|txt|zip|wmv|ssa|r\d{1,3}|7z|tar|mov|divx|m2ts|rmvb|iso|dmg|sub|idx|rm|ac3|t\d{1,2}|u\d{1,3})`
-	rc := types.RegexpUtil{regexp.MustCompile(r)}
-	var parts []types.Part
-	err := d.DB.Where("binary_id is NULL").Find(&parts).Error
-	if err != nil {
-		return err
-	}
-
-	binaries := map[string]*types.Binary{}
-
-	for _, p := range parts {
-
-		m := rc.FindStringSubmatchMap(p.Subject)
-		if len(m) > 0 {
-			for k, v := range m {
-				m[k] = strings.TrimSpace(v)
-			}
-		}
-		// fill name if reqid is available
-		if reqid, ok := m["reqid"]; ok {
-			if _, okname := m["name"]; !okname {
-				m["name"] = reqid
-			}
-		}
-
-		// Generate a name if we don't have one
-		if _, ok := m["name"]; !ok {
-			var matchvalues []string
-			for _, v := range m {
-				matchvalues = append(matchvalues, v)
-			}
-			m["name"] = strings.Join(matchvalues, " ")
-		}
-
-		// Look for parts manually if the regex didn't return some
-		if _, ok := m["parts"]; !ok {
-			partmatch := PartRegex.FindStringSubmatch(p.Subject)
-			if partmatch != nil {
-				m["parts"] = partmatch[1]
-			}
-		}
-		if !hasNameAndParts(m) {
-			fmt.Printf("Couldn't find Name and Parts for %s\n", p.Subject)
-			spew.Dump(m)
-			continue
-		}
-
-		// Clean name of '-', '~', ' of '
-		if strings.Index(m["parts"], "/") == -1 {
-			m["parts"] = strings.Replace(m["parts"], "-", "/", -1)
-			m["parts"] = strings.Replace(m["parts"], "~", "/", -1)
-			m["parts"] = strings.Replace(m["parts"], " of ", "/", -1)
-			m["parts"] = strings.Replace(m["parts"], "[", "", -1)
-			m["parts"] = strings.Replace(m["parts"], "]", "", -1)
-			m["parts"] = strings.Replace(m["parts"], "(", "", -1)
-			m["parts"] = strings.Replace(m["parts"], ")", "", -1)
-		}
-
-		if strings.Index(m["parts"], "/") == -1 {
-			fmt.Printf("Couldn't find valid parts information for %s (%s didn't include /)\n", p.Subject, m["parts"])
-			continue
-		}
-
-		partcounts := strings.SplitN(m["parts"], "/", 2)
-
-		binhash := makeBinaryHash(m["name"], p.Group, p.From, partcounts[1])
-		if bin, ok := binaries[binhash]; ok {
-			bin.Parts = append(bin.Parts, p)
-		} else {
-			totalparts, _ := strconv.Atoi(partcounts[1])
-			binaries[binhash] = &types.Binary{
-				Hash:       binhash,
-				Name:       m["name"],
-				Posted:     p.Posted,
-				From:       p.From,
-				Parts:      []types.Part{p},
-				Group:      p.Group,
-				TotalParts: totalparts,
-			}
-		}
-		err = d.DB.Save(binaries[binhash]).Error
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
 var removeChars = []string{"#", "@", "$", "%", "^", "§", "¨", "©", "Ö"}
 var spaceChars = []string{"_", ".", "-"}
 
@@ -290,39 +234,48 @@ func cleanReleaseName(name string) string {
 
 // MakeReleases comment
 func (d *Handle) MakeReleases() error {
-	var binaries []types.Binary
-	q := `SELECT binary.id, binary.name, binary.posted, binary.total_parts, binary.'group'
-	FROM binary
-	INNER JOIN (
-			SELECT
-					part.id, part.binary_id, part.total_segments, count(*) as available_segments
-			FROM part
-					INNER JOIN segment ON part.id = segment.part_id
-			GROUP BY part.id
-			) as part
-			ON binary.id = part.binary_id
-	GROUP BY binary.id
-	HAVING count(*) >= binary.total_parts AND (sum(part.available_segments) / sum(part.total_segments)) * 100 >= ?
-	ORDER BY binary.posted DESC`
-	err := d.DB.Raw(q, 100).Scan(&binaries).Error
+	binaries, err := d.parts.ReadyBinaries(100)
+	if err != nil {
+		return err
+	}
+
+	cat, err := categorize.NewDefaultCategorizer(d)
+	if err != nil {
+		return err
+	}
+
+	bl, err := blacklist.New(d)
 	if err != nil {
 		return err
 	}
+
 	for _, b := range binaries {
-		// See if a Release already exists for this binary name/date
-		dbrel := &types.Release{}
-		err := d.DB.Where("name = ? and posted = ?", b.Name, b.Posted).First(&dbrel).Error
-		if err != nil && err != gorm.RecordNotFound {
+		if !bl.Allowed(b.Group, b.Name, b.From) {
+			logrus.Infof("Binary blacklisted, dropping: %s", b.Name)
+			// Remove it outright so it isn't re-fetched by ReadyBinaries
+			// and re-checked against the blacklist on every future run.
+			if err := d.parts.DeleteForBinary(b.ID); err != nil {
+				return err
+			}
+			if err := d.DB.Delete(&types.Binary{ID: b.ID}).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		// See if a Release already exists for this binary name/date. The
+		// bloom filter in releaseIndex makes this cheap for the common case
+		// of a binary that hasn't been seen before.
+		exists, err := d.ReleaseExists(b.Name, b.Posted)
+		if err != nil {
 			return err
 		}
-		if dbrel.ID != 0 {
+		if exists {
 			logrus.Infof("Duplicate Binary found, deleting: %s", b.Name)
 			//Delete here
 			continue
 		}
 
-		dbbin := &types.Binary{}
-		err = d.DB.Preload("Parts").Preload("Parts.Segments").First(dbbin, b.ID).Error
+		dbbin, err := d.parts.LoadFull(b.ID)
 		if err != nil {
 			return err
 		}
@@ -337,19 +290,20 @@ func (d *Handle) MakeReleases() error {
 		if err != nil {
 			return err
 		}
+		searchName := cleanReleaseName(b.Name)
 		newrel := &types.Release{
 			Name:         b.Name,
 			OriginalName: b.Name,
-			SearchName:   cleanReleaseName(b.Name),
+			SearchName:   searchName,
 			Posted:       b.Posted,
 			From:         b.From,
 			Group:        *grp,
 			Size:         dbbin.Size(),
 			NZB:          nzbstr,
+			CategoryID:   cat.Categorize(searchName, grp.Name),
+			ContentHash:  nzb.ContentHash(dbbin),
 		}
 
-		// Categorize
-
 		// Check if size is too small
 		// Check if too few files
 		tx := d.DB.Begin()
@@ -358,28 +312,22 @@ func (d *Handle) MakeReleases() error {
 			tx.Rollback()
 			return err
 		}
-		partids := make([]int64, len(dbbin.Parts))
-		for i, p := range dbbin.Parts {
-			partids[i] = p.ID
-		}
-		err = tx.Where("binary_id = ?", dbbin.ID).Delete(types.Part{}).Error
+		err = tx.Delete(dbbin).Error
 		if err != nil {
 			tx.Rollback()
 			return err
 		}
-
-		err = tx.Where("part_id in (?)", partids).Delete(types.Segment{}).Error
-		if err != nil {
-			tx.Rollback()
+		if err := tx.Commit().Error; err != nil {
 			return err
 		}
-
-		err = tx.Delete(dbbin).Error
-		if err != nil {
-			tx.Rollback()
+		// Only drop the Parts/Segments once the Release is durably
+		// committed, so a failed or rolled-back commit never loses them -
+		// parts.DeleteForBinary can't itself be part of tx since PartStore
+		// may be backed by a separate store (e.g. leveldb).
+		if err := d.parts.DeleteForBinary(dbbin.ID); err != nil {
 			return err
 		}
-		tx.Commit()
+		d.recordRelease(newrel.Name, newrel.Posted)
 	}
 	return nil
 }
\ No newline at end of file
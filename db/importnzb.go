@@ -0,0 +1,89 @@
+package db
+
+import (
+	"github.com/hobeone/gonab/categorize"
+	"github.com/hobeone/gonab/nzb"
+	"github.com/hobeone/gonab/types"
+	"github.com/lib/pq"
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// isDuplicateContentHashErr reports whether err is the unique constraint
+// violation on Release.ContentHash, for either the sqlite3 or postgres
+// driver. Two importnzb workers racing on the same content both pass the
+// ReleaseContentHashExists check and both attempt to insert; this is what
+// turns the loser into a skip instead of a duplicate release.
+func isDuplicateContentHashErr(err error) bool {
+	switch e := err.(type) {
+	case sqlite3.Error:
+		return e.Code == sqlite3.ErrConstraint
+	case *pq.Error:
+		return e.Code == "23505"
+	}
+	return false
+}
+
+// findOrCreateGroup returns the Group with the given name, creating an
+// inactive one if it doesn't exist yet. Imported NZBs routinely reference
+// groups gonab has never scanned itself.
+func (d *Handle) findOrCreateGroup(name string) (*types.Group, error) {
+	grp, err := d.FindGroupByName(name)
+	if err == nil {
+		return grp, nil
+	}
+	grp = &types.Group{Name: name}
+	if err := d.DB.Save(grp).Error; err != nil {
+		return nil, err
+	}
+	return grp, nil
+}
+
+// ImportRelease saves bin - a Binary reconstructed by nzb.ParseNZB from an
+// externally-produced .nzb file - directly as a Release, skipping the
+// binaries/parts staging tables MakeReleases normally assembles through.
+// meta carries the NZB's <head> tags. cat categorizes the release the same
+// way MakeReleases does. ImportRelease is a no-op (imported=false) if a
+// Release with the same content hash has already been imported.
+func (d *Handle) ImportRelease(bin *types.Binary, meta map[string]string, cat categorize.Categorizer) (imported bool, err error) {
+	hash := nzb.ContentHash(bin)
+	exists, err := d.ReleaseContentHashExists(hash)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		return false, nil
+	}
+
+	grp, err := d.findOrCreateGroup(bin.Group)
+	if err != nil {
+		return false, err
+	}
+
+	nzbStr, err := nzb.WriteNZB(bin)
+	if err != nil {
+		return false, err
+	}
+
+	searchName := cleanReleaseName(bin.Name)
+	rel := &types.Release{
+		Name:         bin.Name,
+		OriginalName: bin.Name,
+		SearchName:   searchName,
+		Posted:       bin.Posted,
+		From:         bin.From,
+		Group:        *grp,
+		Size:         bin.Size(),
+		NZB:          nzbStr,
+		CategoryID:   cat.Categorize(searchName, grp.Name),
+		Meta:         types.MetaMap(meta),
+		ContentHash:  hash,
+	}
+	if err := d.DB.Save(rel).Error; err != nil {
+		if isDuplicateContentHashErr(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	d.recordRelease(rel.Name, rel.Posted)
+	return true, nil
+}
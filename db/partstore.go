@@ -0,0 +1,293 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hobeone/gonab/config"
+	"github.com/hobeone/gonab/types"
+	"github.com/jinzhu/gorm"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// PartStore persists Part/Segment rows, which see far more churn than
+// Group/Release/Binary and so can optionally live in a separate,
+// non-relational backend.
+type PartStore interface {
+	Create(p *types.Part) error
+	All() ([]types.Part, error)
+	// PartsPage returns up to limit parts with no binary_id, ordered by id,
+	// starting after afterID. Used to page through the table with a stable
+	// cursor instead of loading it all into memory at once.
+	PartsPage(afterID int64, limit int) ([]types.Part, error)
+	// ReadyBinaries returns the Binaries for which at least minPercent of
+	// all their Parts' Segments have been seen.
+	ReadyBinaries(minPercent int) ([]types.Binary, error)
+	// LoadFull returns a Binary with its Parts and their Segments attached.
+	LoadFull(binaryID int64) (*types.Binary, error)
+	DeleteForBinary(binaryID int64) error
+	// DeletePart removes a single unmatched part (and its segments), e.g.
+	// one rejected by the blacklist, so it isn't paged through again by
+	// PartsPage on the next run.
+	DeletePart(partID int64) error
+	// BulkSetBinaryID assigns binaryID to every part in partIDs in one
+	// round-trip.
+	BulkSetBinaryID(partIDs []int64, binaryID int64) error
+}
+
+func newPartStore(cfg *config.DBConfig, db gorm.DB) (PartStore, error) {
+	switch cfg.PartsBackend {
+	case config.PartsBackendLevelDB:
+		return newLevelPartStore(cfg.PartsPath, db)
+	default:
+		return &sqlPartStore{db: db}, nil
+	}
+}
+
+// sqlPartStore keeps Part/Segment rows in the same relational database as
+// everything else. This is the default and matches gonab's original
+// behaviour.
+type sqlPartStore struct {
+	db gorm.DB
+}
+
+func (s *sqlPartStore) Create(p *types.Part) error {
+	return s.db.Save(p).Error
+}
+
+func (s *sqlPartStore) All() ([]types.Part, error) {
+	var parts []types.Part
+	err := s.db.Preload("Segments").Find(&parts).Error
+	return parts, err
+}
+
+func (s *sqlPartStore) PartsPage(afterID int64, limit int) ([]types.Part, error) {
+	var parts []types.Part
+	err := s.db.Where("(binary_id is NULL OR binary_id = 0) AND id > ?", afterID).Order("id").Limit(limit).Find(&parts).Error
+	return parts, err
+}
+
+func (s *sqlPartStore) BulkSetBinaryID(partIDs []int64, binaryID int64) error {
+	if len(partIDs) == 0 {
+		return nil
+	}
+	return s.db.Model(&types.Part{}).Where("id in (?)", partIDs).Update("binary_id", binaryID).Error
+}
+
+// groupColumn quotes the reserved "group" column the way each dialect's SQL
+// parser expects it.
+func groupColumn(db gorm.DB) string {
+	if db.Dialect().GetName() == "postgres" {
+		return `binary."group"`
+	}
+	return `binary.'group'`
+}
+
+func (s *sqlPartStore) ReadyBinaries(minPercent int) ([]types.Binary, error) {
+	var binaries []types.Binary
+	q := fmt.Sprintf(`SELECT binary.id, binary.name, binary.posted, binary.total_parts, %s
+	FROM binary
+	INNER JOIN (
+			SELECT
+					part.id, part.binary_id, part.total_segments, count(*) as available_segments
+			FROM part
+					INNER JOIN segment ON part.id = segment.part_id
+			GROUP BY part.id
+			) as part
+			ON binary.id = part.binary_id
+	GROUP BY binary.id
+	HAVING count(*) >= binary.total_parts AND (sum(part.available_segments) * 100) / sum(part.total_segments) >= ?
+	ORDER BY binary.posted DESC`, groupColumn(s.db))
+	err := s.db.Raw(q, minPercent).Scan(&binaries).Error
+	return binaries, err
+}
+
+func (s *sqlPartStore) LoadFull(binaryID int64) (*types.Binary, error) {
+	bin := &types.Binary{}
+	err := s.db.Preload("Parts").Preload("Parts.Segments").First(bin, binaryID).Error
+	return bin, err
+}
+
+func (s *sqlPartStore) DeleteForBinary(binaryID int64) error {
+	var partIDs []int64
+	err := s.db.Model(&types.Part{}).Where("binary_id = ?", binaryID).Pluck("id", &partIDs).Error
+	if err != nil {
+		return err
+	}
+	err = s.db.Where("binary_id = ?", binaryID).Delete(types.Part{}).Error
+	if err != nil {
+		return err
+	}
+	return s.db.Where("part_id in (?)", partIDs).Delete(types.Segment{}).Error
+}
+
+func (s *sqlPartStore) DeletePart(partID int64) error {
+	if err := s.db.Where("part_id = ?", partID).Delete(types.Segment{}).Error; err != nil {
+		return err
+	}
+	return s.db.Delete(&types.Part{ID: partID}).Error
+}
+
+// levelPartStore keeps Part rows (with their Segments embedded) in an
+// embedded goleveldb KV store, keyed by part id. There is no secondary
+// index: finding parts still waiting to be matched into a Binary scans every
+// part. Binary readiness still needs Binary.TotalParts from the relational
+// DB, so it's passed in via db.
+type levelPartStore struct {
+	ldb *leveldb.DB
+	db  gorm.DB
+}
+
+func newLevelPartStore(path string, db gorm.DB) (*levelPartStore, error) {
+	ldb, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &levelPartStore{ldb: ldb, db: db}, nil
+}
+
+func partKey(id int64) []byte {
+	return []byte(fmt.Sprintf("part:%020d", id))
+}
+
+func (l *levelPartStore) Create(p *types.Part) error {
+	if p.ID == 0 {
+		// Assign the next id after the highest part key currently stored.
+		iter := l.ldb.NewIterator(util.BytesPrefix([]byte("part:")), nil)
+		var last int64
+		if iter.Last() {
+			fmt.Sscanf(string(iter.Key()), "part:%d", &last)
+		}
+		iter.Release()
+		if err := iter.Error(); err != nil {
+			return err
+		}
+		p.ID = last + 1
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return l.ldb.Put(partKey(p.ID), b, nil)
+}
+
+func (l *levelPartStore) iterate(match func(p *types.Part) bool) ([]types.Part, error) {
+	var parts []types.Part
+	iter := l.ldb.NewIterator(util.BytesPrefix([]byte("part:")), nil)
+	defer iter.Release()
+	for iter.Next() {
+		var p types.Part
+		if err := json.Unmarshal(iter.Value(), &p); err != nil {
+			return nil, err
+		}
+		if match == nil || match(&p) {
+			parts = append(parts, p)
+		}
+	}
+	return parts, iter.Error()
+}
+
+func (l *levelPartStore) All() ([]types.Part, error) {
+	return l.iterate(nil)
+}
+
+// PartsPage ignores afterID/limit's paging benefit (goleveldb iterates the
+// whole keyspace regardless) but keeps the cursor semantics so callers can
+// treat both backends identically: it returns at most limit parts with no
+// binary_id yet, with id > afterID.
+func (l *levelPartStore) PartsPage(afterID int64, limit int) ([]types.Part, error) {
+	all, err := l.iterate(func(p *types.Part) bool { return p.BinaryID == 0 && p.ID > afterID })
+	if err != nil {
+		return nil, err
+	}
+	if len(all) > limit {
+		all = all[:limit]
+	}
+	return all, nil
+}
+
+func (l *levelPartStore) BulkSetBinaryID(partIDs []int64, binaryID int64) error {
+	for _, id := range partIDs {
+		var p types.Part
+		v, err := l.ldb.Get(partKey(id), nil)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(v, &p); err != nil {
+			return err
+		}
+		p.BinaryID = binaryID
+		if err := l.Create(&p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *levelPartStore) ReadyBinaries(minPercent int) ([]types.Binary, error) {
+	var dbBinaries []types.Binary
+	err := l.db.Find(&dbBinaries).Error
+	if err != nil {
+		return nil, err
+	}
+	byID := map[int64]*types.Binary{}
+	for i := range dbBinaries {
+		byID[dbBinaries[i].ID] = &dbBinaries[i]
+	}
+
+	parts, err := l.iterate(func(p *types.Part) bool { return p.BinaryID != 0 })
+	if err != nil {
+		return nil, err
+	}
+	grouped := map[int64][]types.Part{}
+	for _, p := range parts {
+		grouped[p.BinaryID] = append(grouped[p.BinaryID], p)
+	}
+
+	var ready []types.Binary
+	for binID, bparts := range grouped {
+		bin, ok := byID[binID]
+		if !ok || len(bparts) < bin.TotalParts {
+			continue
+		}
+		var available, total int
+		for _, p := range bparts {
+			available += len(p.Segments)
+			total += p.TotalSegments
+		}
+		if total > 0 && (available*100)/total >= minPercent {
+			ready = append(ready, *bin)
+		}
+	}
+	return ready, nil
+}
+
+func (l *levelPartStore) LoadFull(binaryID int64) (*types.Binary, error) {
+	bin := &types.Binary{}
+	if err := l.db.First(bin, binaryID).Error; err != nil {
+		return nil, err
+	}
+	parts, err := l.iterate(func(p *types.Part) bool { return p.BinaryID == binaryID })
+	if err != nil {
+		return nil, err
+	}
+	bin.Parts = parts
+	return bin, nil
+}
+
+func (l *levelPartStore) DeleteForBinary(binaryID int64) error {
+	parts, err := l.iterate(func(p *types.Part) bool { return p.BinaryID == binaryID })
+	if err != nil {
+		return err
+	}
+	batch := new(leveldb.Batch)
+	for _, p := range parts {
+		batch.Delete(partKey(p.ID))
+	}
+	return l.ldb.Write(batch, nil)
+}
+
+func (l *levelPartStore) DeletePart(partID int64) error {
+	return l.ldb.Delete(partKey(partID), nil)
+}
@@ -0,0 +1,170 @@
+package db
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hobeone/gonab/config"
+	"github.com/hobeone/gonab/types"
+)
+
+// newLevelDBTestHandle returns a Handle backed by sqlite for
+// Group/Release/Binary and goleveldb for Part/Segment, so the leveldb
+// PartStore path gets exercised against a real relational Binary table.
+func newLevelDBTestHandle(t *testing.T) *Handle {
+	dbPath, err := ioutil.TempFile("", "gonab-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbPath.Close()
+	ldbPath, err := ioutil.TempDir("", "gonab-leveldb-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Remove(dbPath.Name())
+		os.RemoveAll(ldbPath)
+	})
+
+	cfg := config.NewConfig()
+	cfg.DB.Path = dbPath.Name()
+	cfg.DB.PartsBackend = config.PartsBackendLevelDB
+	cfg.DB.PartsPath = ldbPath
+
+	dbh, err := CreateAndMigrateDB(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dbh
+}
+
+// newPostgresTestHandle returns a Handle backed by postgres, using the DSN
+// in GONAB_TEST_POSTGRES_DSN. It's the motivating backend for groupColumn
+// and upsertBinary's ON CONFLICT path, but isn't available in every
+// environment these tests run in, so it's skipped unless the DSN is set.
+func newPostgresTestHandle(t *testing.T) *Handle {
+	dsn := os.Getenv("GONAB_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("GONAB_TEST_POSTGRES_DSN not set, skipping postgres PartStore tests")
+	}
+
+	cfg := config.NewConfig()
+	cfg.DB.Type = config.DBTypePostgres
+	cfg.DB.DSN = dsn
+
+	dbh, err := CreateAndMigrateDB(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		dbh.DB.Exec(`TRUNCATE segment, part, binary RESTART IDENTITY CASCADE`)
+	})
+	return dbh
+}
+
+// testHandle returns a Handle for the given PartStore backend ("sql",
+// "leveldb" or "postgres").
+func testHandle(t *testing.T, backend string) *Handle {
+	switch backend {
+	case "leveldb":
+		return newLevelDBTestHandle(t)
+	case "postgres":
+		return newPostgresTestHandle(t)
+	default:
+		return NewMemoryDBHandle(false)
+	}
+}
+
+func TestPartStorePartsPage(t *testing.T) {
+	for _, backend := range []string{"sql", "leveldb", "postgres"} {
+		t.Run(backend, func(t *testing.T) {
+			dbh := testHandle(t, backend)
+
+			for i := 0; i < 3; i++ {
+				if err := dbh.CreatePart(&types.Part{Subject: "part"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			page, err := dbh.parts.PartsPage(0, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page) != 2 {
+				t.Fatalf("got %d parts, want 2", len(page))
+			}
+
+			rest, err := dbh.parts.PartsPage(page[len(page)-1].ID, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rest) != 1 {
+				t.Fatalf("got %d parts, want 1", len(rest))
+			}
+		})
+	}
+}
+
+func TestPartStoreReadyBinaries(t *testing.T) {
+	for _, backend := range []string{"sql", "leveldb", "postgres"} {
+		t.Run(backend, func(t *testing.T) {
+			dbh := testHandle(t, backend)
+
+			bin := &types.Binary{Name: "ready.bin", TotalParts: 1}
+			if err := dbh.DB.Save(bin).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			part := &types.Part{
+				BinaryID:      bin.ID,
+				TotalSegments: 1,
+				Segments:      []types.Segment{{Number: 1, Size: 100}},
+			}
+			if err := dbh.CreatePart(part); err != nil {
+				t.Fatal(err)
+			}
+
+			ready, err := dbh.parts.ReadyBinaries(100)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ready) != 1 || ready[0].ID != bin.ID {
+				t.Fatalf("got %+v, want a single ready binary %d", ready, bin.ID)
+			}
+		})
+	}
+}
+
+func TestPartStoreDeleteForBinary(t *testing.T) {
+	for _, backend := range []string{"sql", "leveldb", "postgres"} {
+		t.Run(backend, func(t *testing.T) {
+			dbh := testHandle(t, backend)
+
+			bin := &types.Binary{Name: "delete.bin"}
+			if err := dbh.DB.Save(bin).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			part := &types.Part{
+				BinaryID: bin.ID,
+				Segments: []types.Segment{{Number: 1, Size: 100}},
+			}
+			if err := dbh.CreatePart(part); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := dbh.parts.DeleteForBinary(bin.ID); err != nil {
+				t.Fatal(err)
+			}
+
+			full, err := dbh.parts.LoadFull(bin.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(full.Parts) != 0 {
+				t.Fatalf("got %d parts after delete, want 0", len(full.Parts))
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package db
+
+import "github.com/hobeone/gonab/types"
+
+// ListAllReleases returns every Release, with its Group preloaded.
+func (d *Handle) ListAllReleases() ([]types.Release, error) {
+	var releases []types.Release
+	err := d.DB.Preload("Group").Find(&releases).Error
+	return releases, err
+}
+
+// UpdateReleaseCategory sets CategoryID on the Release with the given id.
+func (d *Handle) UpdateReleaseCategory(id int64, categoryID int64) error {
+	return d.DB.Model(&types.Release{}).Where("id = ?", id).Update("category_id", categoryID).Error
+}
+
+// ReleaseContentHashExists reports whether a Release with the given content
+// hash (see nzb.ContentHash) has already been imported.
+func (d *Handle) ReleaseContentHashExists(hash string) (bool, error) {
+	var count int
+	err := d.DB.Model(&types.Release{}).Where("content_hash = ?", hash).Count(&count).Error
+	return count > 0, err
+}
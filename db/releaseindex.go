@@ -0,0 +1,102 @@
+package db
+
+import (
+	"time"
+
+	"github.com/hobeone/gonab/types"
+	"github.com/willf/bloom"
+)
+
+// releaseIndex is a bloom filter over (name, posted) tuples.
+type releaseIndex struct {
+	filter *bloom.BloomFilter
+}
+
+// releaseIndexFPRate is the filter's false-positive rate.
+const releaseIndexFPRate = 0.01
+
+func releaseKey(name string, posted time.Time) []byte {
+	return []byte(name + "\x00" + posted.UTC().Format(time.RFC3339))
+}
+
+// newReleaseIndex loads every existing (name, posted) pair into a bloom
+// filter sized from the current release count.
+func newReleaseIndex(d *Handle) (*releaseIndex, error) {
+	var count int
+	if err := d.DB.Model(&types.Release{}).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	// Floor the size so a near-empty table doesn't saturate the filter.
+	n := count
+	if n < 4096 {
+		n = 4096
+	}
+	idx := &releaseIndex{filter: bloom.NewWithEstimates(uint(n), releaseIndexFPRate)}
+
+	rows, err := d.DB.Model(&types.Release{}).Select("name, posted").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var posted time.Time
+		if err := rows.Scan(&name, &posted); err != nil {
+			return nil, err
+		}
+		idx.filter.Add(releaseKey(name, posted))
+	}
+	return idx, rows.Err()
+}
+
+func (idx *releaseIndex) maybeExists(name string, posted time.Time) bool {
+	return idx.filter.Test(releaseKey(name, posted))
+}
+
+func (idx *releaseIndex) add(name string, posted time.Time) {
+	idx.filter.Add(releaseKey(name, posted))
+}
+
+// ensureReleaseIndex lazily builds d.relIndex on first use.
+func (d *Handle) ensureReleaseIndex() error {
+	d.relIndexMutex.Lock()
+	defer d.relIndexMutex.Unlock()
+	if d.relIndex != nil {
+		return nil
+	}
+	idx, err := newReleaseIndex(d)
+	if err != nil {
+		return err
+	}
+	d.relIndex = idx
+	return nil
+}
+
+// ReleaseExists reports whether a Release with the given name and posted
+// date already exists. Callers that insert a new Release should follow up
+// with recordRelease to keep the filter current.
+func (d *Handle) ReleaseExists(name string, posted time.Time) (bool, error) {
+	if err := d.ensureReleaseIndex(); err != nil {
+		return false, err
+	}
+	if !d.relIndex.maybeExists(name, posted) {
+		return false, nil
+	}
+
+	var count int
+	err := d.DB.Model(&types.Release{}).Where("name = ? and posted = ?", name, posted).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// recordRelease adds name/posted to the bloom filter after a successful insert.
+func (d *Handle) recordRelease(name string, posted time.Time) {
+	d.relIndexMutex.Lock()
+	defer d.relIndexMutex.Unlock()
+	if d.relIndex != nil {
+		d.relIndex.add(name, posted)
+	}
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hobeone/gonab/types"
+)
+
+// releaseCorpusSize matches the "100k+ binaries" scale the bloom filter
+// was sized for.
+const releaseCorpusSize = 100000
+
+func seedReleases(b *testing.B, dbh *Handle, n int) {
+	tx := dbh.DB.Begin()
+	for i := 0; i < n; i++ {
+		rel := &types.Release{
+			Name:   fmt.Sprintf("release-%d", i),
+			Posted: time.Unix(int64(i), 0),
+			// ContentHash is uniquely indexed, so each row needs its own.
+			ContentHash: fmt.Sprintf("hash-%d", i),
+		}
+		if err := tx.Save(rel).Error; err != nil {
+			tx.Rollback()
+			b.Fatal(err)
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		b.Fatal(err)
+	}
+}
+
+// BenchmarkReleaseExists_Bloom times the bloom-filter-backed duplicate
+// check against a seeded corpus of 100k+ releases.
+func BenchmarkReleaseExists_Bloom(b *testing.B) {
+	dbh := NewMemoryDBHandle(false)
+	seedReleases(b, dbh, releaseCorpusSize)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		name := fmt.Sprintf("release-%d", i%releaseCorpusSize)
+		posted := time.Unix(int64(i%releaseCorpusSize), 0)
+		if _, err := dbh.ReleaseExists(name, posted); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkReleaseExists_SQL times the plain per-binary SELECT MakeReleases
+// used before releaseIndex, for comparison against the bloom-backed path.
+func BenchmarkReleaseExists_SQL(b *testing.B) {
+	dbh := NewMemoryDBHandle(false)
+	seedReleases(b, dbh, releaseCorpusSize)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		name := fmt.Sprintf("release-%d", i%releaseCorpusSize)
+		posted := time.Unix(int64(i%releaseCorpusSize), 0)
+		var count int
+		err := dbh.DB.Model(&types.Release{}).Where("name = ? and posted = ?", name, posted).Count(&count).Error
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
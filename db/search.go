@@ -0,0 +1,33 @@
+package db
+
+import "github.com/hobeone/gonab/types"
+
+// SearchReleases returns Releases whose SearchName matches query (a plain
+// substring match), optionally scoped to a category, paginated via
+// offset/limit. It also returns the total number of matches so callers can
+// build Newznab's <response> paging attributes.
+func (d *Handle) SearchReleases(query string, category int64, offset, limit int) ([]types.Release, int, error) {
+	q := d.DB.Model(&types.Release{})
+	if query != "" {
+		q = q.Where("search_name LIKE ?", "%"+query+"%")
+	}
+	if category != 0 {
+		q = q.Where("category_id = ?", category)
+	}
+
+	var total int
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var releases []types.Release
+	err := q.Preload("Group").Order("posted desc").Offset(offset).Limit(limit).Find(&releases).Error
+	return releases, total, err
+}
+
+// GetRelease returns a single Release by id, used to serve its stored NZB.
+func (d *Handle) GetRelease(id int64) (*types.Release, error) {
+	var r types.Release
+	err := d.DB.First(&r, id).Error
+	return &r, err
+}
@@ -0,0 +1,15 @@
+package db
+
+import "github.com/hobeone/gonab/types"
+
+// FindUserByAPIKey looks up a User by their Newznab API key.
+func (d *Handle) FindUserByAPIKey(key string) (*types.User, error) {
+	var u types.User
+	err := d.DB.Where("api_key = ?", key).First(&u).Error
+	return &u, err
+}
+
+// CreateUser saves a new User.
+func (d *Handle) CreateUser(u *types.User) error {
+	return d.DB.Save(u).Error
+}
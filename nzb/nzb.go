@@ -0,0 +1,156 @@
+// Package nzb reads and writes NZB files, the XML format used to describe
+// where to find a Binary's segments on Usenet.
+package nzb
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/OneOfOne/xxhash"
+	"github.com/hobeone/gonab/types"
+)
+
+type nzbFile struct {
+	XMLName xml.Name   `xml:"nzb"`
+	Head    nzbHead    `xml:"head"`
+	Files   []nzbEntry `xml:"file"`
+}
+
+type nzbHead struct {
+	Meta []nzbMeta `xml:"meta"`
+}
+
+type nzbMeta struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type nzbEntry struct {
+	Poster   string      `xml:"poster,attr"`
+	Date     int64       `xml:"date,attr"`
+	Subject  string      `xml:"subject,attr"`
+	Groups   []string    `xml:"groups>group"`
+	Segments []nzbSegment `xml:"segments>segment"`
+}
+
+type nzbSegment struct {
+	Bytes  int64  `xml:"bytes,attr"`
+	Number int    `xml:"number,attr"`
+	ID     string `xml:",chardata"`
+}
+
+// WriteNZB renders a fully assembled Binary as an NZB document.
+func WriteNZB(b *types.Binary) (string, error) {
+	doc := nzbFile{
+		Files: make([]nzbEntry, len(b.Parts)),
+	}
+	for i, p := range b.Parts {
+		entry := nzbEntry{
+			Poster:  p.From,
+			Date:    p.Posted.Unix(),
+			Subject: p.Subject,
+			Groups:  []string{p.Group},
+		}
+		for _, s := range p.Segments {
+			entry.Segments = append(entry.Segments, nzbSegment{
+				Bytes:  s.Size,
+				Number: s.Number,
+				ID:     s.MessageID,
+			})
+		}
+		doc.Files[i] = entry
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// ParsedNZB is the result of reading an NZB document: the Binary it
+// describes (with its Parts and Segments populated, but not yet saved),
+// plus whatever <head> meta tags it carried.
+type ParsedNZB struct {
+	Binary *types.Binary
+	Meta   map[string]string
+}
+
+// ParseNZB is the inverse of WriteNZB: it reads an NZB document and
+// reconstructs the Binary, Part and Segment rows it describes, ready for a
+// caller to save directly without going through the usual part/binary
+// ingest pipeline.
+func ParseNZB(r io.Reader) (*ParsedNZB, error) {
+	var doc nzbFile
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	meta := make(map[string]string, len(doc.Head.Meta))
+	for _, m := range doc.Head.Meta {
+		meta[m.Type] = m.Value
+	}
+
+	bin := &types.Binary{
+		TotalParts: len(doc.Files),
+		Parts:      make([]types.Part, len(doc.Files)),
+	}
+	for i, f := range doc.Files {
+		part := types.Part{
+			Subject:       f.Subject,
+			From:          f.Poster,
+			Posted:        time.Unix(f.Date, 0),
+			TotalSegments: len(f.Segments),
+			Segments:      make([]types.Segment, len(f.Segments)),
+		}
+		if len(f.Groups) > 0 {
+			part.Group = f.Groups[0]
+		}
+		for j, s := range f.Segments {
+			part.Segments[j] = types.Segment{
+				MessageID: s.ID,
+				Number:    s.Number,
+				Size:      s.Bytes,
+			}
+		}
+		if len(f.Segments) > 0 {
+			part.MessageID = f.Segments[0].ID
+		}
+		bin.Parts[i] = part
+
+		if i == 0 {
+			bin.Name = types.ExtractBinaryName(f.Subject)
+			bin.From = f.Poster
+			bin.Posted = part.Posted
+			bin.Group = part.Group
+		}
+	}
+
+	return &ParsedNZB{Binary: bin, Meta: meta}, nil
+}
+
+// ContentHash identifies a Binary by the sorted set of its segment
+// message-ids, so the same content can be recognised even if it was
+// reposted under a different subject.
+func ContentHash(b *types.Binary) string {
+	var ids []string
+	for _, p := range b.Parts {
+		for _, s := range p.Segments {
+			ids = append(ids, s.MessageID)
+		}
+	}
+	sort.Strings(ids)
+
+	h := xxhash.New64()
+	for _, id := range ids {
+		h.Write([]byte(id))
+	}
+	return fmt.Sprintf("%x", h.Sum64())
+}
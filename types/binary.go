@@ -0,0 +1,28 @@
+package types
+
+import "time"
+
+// Binary is a complete multi-part post, assembled from its constituent
+// Parts once enough of them have been seen.
+type Binary struct {
+	ID         int64  `gorm:"primary_key"`
+	Hash       string `gorm:"unique_index"`
+	Name       string
+	Posted     time.Time
+	From       string
+	Group      string
+	TotalParts int
+	Parts      []Part
+}
+
+// Size returns the total size in bytes of all segments gonab has seen for
+// this binary.
+func (b *Binary) Size() int64 {
+	var size int64
+	for _, p := range b.Parts {
+		for _, s := range p.Segments {
+			size += s.Size
+		}
+	}
+	return size
+}
@@ -0,0 +1,31 @@
+package types
+
+import (
+	"regexp"
+	"strings"
+)
+
+// BinarySubjectRegex pulls the filename (and part count) out of a Usenet
+// posting subject, e.g. `[001/150] - "Show.Name.S01E02.1080p.mkv" yEnc (1/50)`.
+var BinarySubjectRegex = regexp.MustCompile(`(?i).*?(?P<parts>\d{1,3}\/\d{1,3}).*?\"(?P<name>.*?)\.(sample|mkv|Avi|mp4|vol|ogm|par|rar|sfv|nfo|nzb|srt|ass|mpg|txt|zip|wmv|ssa|r\d{1,3}|7z|tar|mov|divx|m2ts|rmvb|iso|dmg|sub|idx|rm|ac3|t\d{1,2}|u\d{1,3})`)
+
+// ExtractBinaryName derives a release name from a posting subject the same
+// way the binary-matching ingest path does, so names are consistent
+// whether a release was assembled from parts or imported from an NZB.
+func ExtractBinaryName(subject string) string {
+	m := RegexpUtil{BinarySubjectRegex}.FindStringSubmatchMap(subject)
+	for k, v := range m {
+		m[k] = strings.TrimSpace(v)
+	}
+	if name, ok := m["name"]; ok && name != "" {
+		return name
+	}
+	if len(m) == 0 {
+		return subject
+	}
+	var matchvalues []string
+	for _, v := range m {
+		matchvalues = append(matchvalues, v)
+	}
+	return strings.Join(matchvalues, " ")
+}
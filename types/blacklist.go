@@ -0,0 +1,25 @@
+package types
+
+// MsgCol identifies which field of a part or release a Blacklist rule
+// matches its Regex against.
+type MsgCol string
+
+// Supported MsgCol values.
+const (
+	MsgColSubject MsgCol = "subject"
+	MsgColFrom    MsgCol = "from"
+	MsgColGroup   MsgCol = "group"
+)
+
+// Blacklist is a group-scoped regex rule used to drop matching content
+// before it's indexed. When Whitelist is true the rule is inverted: content
+// is kept only if it matches, which suits single-topic indexers.
+type Blacklist struct {
+	ID          int64 `gorm:"primary_key"`
+	GroupRegex  string
+	Regex       string
+	MsgCol      MsgCol
+	Whitelist   bool
+	Status      bool
+	Description string
+}
@@ -0,0 +1,33 @@
+package types
+
+// Newznab category IDs. Parent categories are round thousands; children are
+// offsets within their parent's range. See
+// https://github.com/nZEDb/nZEDb/blob/master/misc/update/nzedb/categories.xml
+// for the scheme gonab mirrors.
+const (
+	CategoryOther     = 0
+	CategoryOtherMisc = 10
+
+	CategoryMovies   = 2000
+	CategoryMoviesSD = 2030
+	CategoryMoviesHD = 2040
+
+	CategoryTV   = 5000
+	CategoryTVSD = 5030
+	CategoryTVHD = 5040
+
+	CategoryBooks      = 7000
+	CategoryBooksEBook = 7020
+)
+
+// CategoryRegex maps a subject/group-matching regex to the category it
+// identifies, mirroring nzedb's category_regexes table.
+type CategoryRegex struct {
+	ID          int64 `gorm:"primary_key"`
+	GroupRegex  string
+	Regex       string
+	CategoryID  int64
+	Ordinal     int
+	Status      bool
+	Description string
+}
@@ -0,0 +1,8 @@
+package types
+
+// Group is a single Usenet newsgroup gonab scans for parts.
+type Group struct {
+	ID     int64  `gorm:"primary_key"`
+	Name   string `gorm:"unique_index"`
+	Active bool
+}
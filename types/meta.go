@@ -0,0 +1,45 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// MetaMap is a map[string]string persisted as a JSON blob, used for
+// Release.Meta.
+type MetaMap map[string]string
+
+// Value implements driver.Valuer so gorm can store a MetaMap as a text column.
+func (m MetaMap) Value() (driver.Value, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner so gorm can hydrate a MetaMap from its text column.
+func (m *MetaMap) Scan(src interface{}) error {
+	if src == nil {
+		*m = nil
+		return nil
+	}
+	var b []byte
+	switch v := src.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("types: cannot scan %T into MetaMap", src)
+	}
+	if len(b) == 0 {
+		*m = nil
+		return nil
+	}
+	return json.Unmarshal(b, m)
+}
@@ -0,0 +1,42 @@
+package types
+
+import (
+	"regexp"
+	"time"
+)
+
+// Part is a single multi-segment post as seen on the wire, before it has
+// been matched up with its siblings into a Binary.
+type Part struct {
+	ID            int64 `gorm:"primary_key"`
+	MessageID     string
+	Subject       string
+	From          string
+	Group         string
+	Posted        time.Time
+	TotalSegments int
+	BinaryID      int64 `gorm:"index"`
+	Segments      []Segment
+}
+
+// RegexpUtil wraps a *regexp.Regexp to expose named capture groups as a map.
+type RegexpUtil struct {
+	*regexp.Regexp
+}
+
+// FindStringSubmatchMap runs the regex against s and returns its named
+// capture groups as a map, skipping unnamed and unmatched groups.
+func (r RegexpUtil) FindStringSubmatchMap(s string) map[string]string {
+	match := r.FindStringSubmatch(s)
+	result := map[string]string{}
+	if match == nil {
+		return result
+	}
+	for i, name := range r.SubexpNames() {
+		if i == 0 || name == "" || match[i] == "" {
+			continue
+		}
+		result[name] = match[i]
+	}
+	return result
+}
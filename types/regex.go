@@ -0,0 +1,12 @@
+package types
+
+// Regex is a single nzedb-style group/subject matching rule, imported via
+// RegexImporter and used to pull Name/parts metadata out of raw subjects.
+type Regex struct {
+	ID          int64 `gorm:"primary_key"`
+	GroupName   string
+	Regex       string
+	Ordinal     int
+	Status      bool
+	Description string
+}
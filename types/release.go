@@ -0,0 +1,27 @@
+package types
+
+import "time"
+
+// Release is a fully assembled, de-duplicated Binary ready to be served by
+// the indexer.
+type Release struct {
+	ID           int64 `gorm:"primary_key"`
+	Name         string
+	OriginalName string
+	SearchName   string `gorm:"index"`
+	Posted       time.Time
+	From         string
+	GroupID      int64
+	Group        Group
+	Size         int64
+	NZB          string
+	// CategoryID is the Newznab category this release was sorted into. See
+	// the Category* constants.
+	CategoryID int64
+	// Meta holds the <head> metadata carried by an imported NZB (e.g.
+	// password, tag). Empty for releases gonab assembled itself.
+	Meta MetaMap `gorm:"type:text"`
+	// ContentHash identifies a release by its segment message-ids, unique
+	// so racing inserts of the same content fail instead of duplicating.
+	ContentHash string `gorm:"unique_index"`
+}
@@ -0,0 +1,10 @@
+package types
+
+// Segment is a single article making up part of a Part.
+type Segment struct {
+	ID        int64 `gorm:"primary_key"`
+	PartID    int64 `gorm:"index"`
+	MessageID string
+	Number    int
+	Size      int64
+}
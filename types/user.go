@@ -0,0 +1,11 @@
+package types
+
+// User is a Newznab API consumer, authenticated by APIKey.
+type User struct {
+	ID       int64  `gorm:"primary_key"`
+	Username string `gorm:"unique_index"`
+	APIKey   string `gorm:"unique_index"`
+	Email    string
+	// IsAdmin lets a User call t=register to mint API keys for others.
+	IsAdmin bool
+}